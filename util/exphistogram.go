@@ -0,0 +1,325 @@
+package util
+
+import (
+	"math"
+	"sort"
+)
+
+const defaultExpHistogramMaxBuckets = 160
+
+// ExpHistogram is a sparse, base-2 exponential-bucket histogram for
+// constant-memory distribution tracking across arbitrarily long OHLCV
+// windows. Schema controls bucket width: boundaries sit at 2^(2^-schema), so
+// schema=0 gives one bucket per octave (powers of two) and schema=3 gives
+// eight buckets per octave. Observations are bucketed by magnitude into
+// positive/negative sparse maps keyed by signed bucket index, plus a
+// dedicated zero bucket, a running sum, and a running count.
+type ExpHistogram struct {
+	schema     int
+	zeroCount  int64
+	positive   map[int]int64
+	negative   map[int]int64
+	sum        float64
+	count      int64
+	maxBuckets int
+}
+
+// NewExpHistogram creates a histogram at the given schema. maxBuckets caps
+// how many sparse buckets are kept before the schema is lowered by one and
+// adjacent buckets merged; a value <=0 uses a sane default.
+func NewExpHistogram(schema, maxBuckets int) *ExpHistogram {
+	if maxBuckets <= 0 {
+		maxBuckets = defaultExpHistogramMaxBuckets
+	}
+	return &ExpHistogram{
+		schema:     schema,
+		positive:   make(map[int]int64),
+		negative:   make(map[int]int64),
+		maxBuckets: maxBuckets,
+	}
+}
+
+// bucketIndex returns i = floor(log2(magnitude) * 2^schema), the bucket a
+// positive magnitude falls into. This must stay a floor (not ceil)
+// convention: downscale lowers the schema by one and merges bucket pairs (i, i+1)
+// under floor(i/2), which only lines up with the coarser schema's
+// boundaries when indices are floor-assigned in the first place.
+func (h *ExpHistogram) bucketIndex(magnitude float64) int {
+	return int(math.Floor(math.Log2(magnitude) * math.Pow(2, float64(h.schema))))
+}
+
+// bucketBounds returns the [lower, upper) magnitude range bucket idx
+// covers.
+func (h *ExpHistogram) bucketBounds(idx int) (lower, upper float64) {
+	base := math.Pow(2, 1/math.Pow(2, float64(h.schema)))
+	lower = math.Pow(base, float64(idx))
+	upper = math.Pow(base, float64(idx+1))
+	return lower, upper
+}
+
+// Observe adds x to the histogram.
+func (h *ExpHistogram) Observe(x float64) {
+	h.count++
+	h.sum += x
+
+	if x == 0 {
+		h.zeroCount++
+		return
+	}
+
+	idx := h.bucketIndex(math.Abs(x))
+	if x > 0 {
+		h.positive[idx]++
+	} else {
+		h.negative[idx]++
+	}
+
+	if len(h.positive)+len(h.negative) > h.maxBuckets {
+		h.downscale()
+	}
+}
+
+// downscale lowers the schema by one, halving the buckets per octave, and
+// merges adjacent bucket pairs i and i+1 under floor(i/2), trading resolution
+// for bounded memory.
+func (h *ExpHistogram) downscale() {
+	h.schema--
+	h.positive = mergeAdjacentBuckets(h.positive)
+	h.negative = mergeAdjacentBuckets(h.negative)
+}
+
+func mergeAdjacentBuckets(buckets map[int]int64) map[int]int64 {
+	merged := make(map[int]int64, len(buckets)/2+1)
+	for idx, count := range buckets {
+		merged[floorDiv(idx, 2)] += count
+	}
+	return merged
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// Merge folds other's observations into h, downscaling whichever side has
+// the finer schema first so bucket indices line up.
+func (h *ExpHistogram) Merge(other *ExpHistogram) {
+	if other == nil {
+		return
+	}
+
+	incoming := other
+	if incoming.schema > h.schema {
+		incoming = incoming.clone()
+		for incoming.schema > h.schema {
+			incoming.downscale()
+		}
+	}
+	for h.schema > incoming.schema {
+		h.downscale()
+	}
+
+	h.zeroCount += incoming.zeroCount
+	h.sum += incoming.sum
+	h.count += incoming.count
+	for idx, c := range incoming.positive {
+		h.positive[idx] += c
+	}
+	for idx, c := range incoming.negative {
+		h.negative[idx] += c
+	}
+
+	if len(h.positive)+len(h.negative) > h.maxBuckets {
+		h.downscale()
+	}
+}
+
+func (h *ExpHistogram) clone() *ExpHistogram {
+	return &ExpHistogram{
+		schema:     h.schema,
+		zeroCount:  h.zeroCount,
+		positive:   cloneBucketMap(h.positive),
+		negative:   cloneBucketMap(h.negative),
+		sum:        h.sum,
+		count:      h.count,
+		maxBuckets: h.maxBuckets,
+	}
+}
+
+func cloneBucketMap(buckets map[int]int64) map[int]int64 {
+	out := make(map[int]int64, len(buckets))
+	for k, v := range buckets {
+		out[k] = v
+	}
+	return out
+}
+
+// Quantile returns an estimate of the phi-quantile (0<=phi<=1) via linear
+// interpolation inside the bucket the target rank falls in.
+func (h *ExpHistogram) Quantile(phi float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(phi * float64(h.count)))
+
+	negIdx := sortedBucketIndexes(h.negative)
+	for i := len(negIdx) - 1; i >= 0; i-- {
+		idx := negIdx[i]
+		c := h.negative[idx]
+		if c >= target {
+			lower, upper := h.bucketBounds(idx)
+			frac := float64(target) / float64(c)
+			return -(upper - frac*(upper-lower))
+		}
+		target -= c
+	}
+
+	if target <= h.zeroCount {
+		return 0
+	}
+	target -= h.zeroCount
+
+	posIdx := sortedBucketIndexes(h.positive)
+	for _, idx := range posIdx {
+		c := h.positive[idx]
+		if c >= target {
+			lower, upper := h.bucketBounds(idx)
+			frac := float64(target) / float64(c)
+			return lower + frac*(upper-lower)
+		}
+		target -= c
+	}
+
+	if len(posIdx) == 0 {
+		return 0
+	}
+	_, upper := h.bucketBounds(posIdx[len(posIdx)-1])
+	return upper
+}
+
+// CDF returns the fraction of observations at or below x.
+func (h *ExpHistogram) CDF(x float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+
+	var below float64
+	for idx, c := range h.negative {
+		lower, upper := h.bucketBounds(idx) // bucket covers magnitudes [lower, upper)
+		valueUpper, valueLower := -lower, -upper
+		switch {
+		case x >= valueUpper:
+			below += float64(c)
+		case x > valueLower:
+			below += float64(c) * (x - valueLower) / (valueUpper - valueLower)
+		}
+	}
+
+	if x >= 0 {
+		below += float64(h.zeroCount)
+	}
+
+	for idx, c := range h.positive {
+		lower, upper := h.bucketBounds(idx)
+		switch {
+		case x >= upper:
+			below += float64(c)
+		case x > lower:
+			below += float64(c) * (x - lower) / (upper - lower)
+		}
+	}
+
+	return below / float64(h.count)
+}
+
+// meanAndStdDev estimates the histogram's mean and standard deviation from
+// its running sum/count and per-bucket midpoints; individual observations
+// are not retained once bucketed.
+func (h *ExpHistogram) meanAndStdDev() (float64, float64) {
+	if h.count == 0 {
+		return 0, 0
+	}
+	mean := h.sum / float64(h.count)
+
+	var variance float64
+	for idx, c := range h.positive {
+		lower, upper := h.bucketBounds(idx)
+		mid := (lower + upper) / 2
+		variance += float64(c) * (mid - mean) * (mid - mean)
+	}
+	for idx, c := range h.negative {
+		lower, upper := h.bucketBounds(idx)
+		mid := -(lower + upper) / 2
+		variance += float64(c) * (mid - mean) * (mid - mean)
+	}
+	variance += float64(h.zeroCount) * mean * mean
+
+	return mean, math.Sqrt(variance / float64(h.count))
+}
+
+func sortedBucketIndexes(buckets map[int]int64) []int {
+	idx := make([]int, 0, len(buckets))
+	for k := range buckets {
+		idx = append(idx, k)
+	}
+	sort.Ints(idx)
+	return idx
+}
+
+// ObserveOHLCVVolumes feeds each row's volume into a per-symbol histogram
+// (created lazily at schema on first use), so ResampleOHLCV's output can be
+// folded straight into constant-memory distribution tracking.
+func ObserveOHLCVVolumes(data []map[string]interface{}, histograms map[string]*ExpHistogram, schema int) {
+	for _, row := range data {
+		symbol, ok := row["symbol"].(string)
+		if !ok {
+			continue
+		}
+		volume, ok := row["volume"].(float64)
+		if !ok {
+			continue
+		}
+
+		h, ok := histograms[symbol]
+		if !ok {
+			h = NewExpHistogram(schema, 0)
+			histograms[symbol] = h
+		}
+		h.Observe(volume)
+	}
+}
+
+// ObserveOHLCVReturns feeds each row's close-over-previous-close return into
+// a per-symbol histogram the same way ObserveOHLCVVolumes does for volume.
+// data is assumed to be in chronological order per symbol, as ResampleOHLCV
+// produces.
+func ObserveOHLCVReturns(data []map[string]interface{}, histograms map[string]*ExpHistogram, schema int) {
+	lastClose := make(map[string]float64)
+
+	for _, row := range data {
+		symbol, ok := row["symbol"].(string)
+		if !ok {
+			continue
+		}
+		closeVal, ok := row["close"].(float64)
+		if !ok {
+			continue
+		}
+
+		prev, seen := lastClose[symbol]
+		lastClose[symbol] = closeVal
+		if !seen || prev == 0 {
+			continue
+		}
+
+		h, ok := histograms[symbol]
+		if !ok {
+			h = NewExpHistogram(schema, 0)
+			histograms[symbol] = h
+		}
+		h.Observe((closeVal - prev) / prev)
+	}
+}
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExpHistogramBucketBoundsContainTheValueTheyWereAssignedFrom(t *testing.T) {
+	h := NewExpHistogram(4, 0)
+
+	const eps = 1e-9
+	for _, x := range []float64{1, 2, 3.5, 10, 100, 0.25, 0.01} {
+		idx := h.bucketIndex(x)
+		lower, upper := h.bucketBounds(idx)
+		if x < lower-eps || x >= upper+eps {
+			t.Errorf("bucketIndex(%v) = %d, but bounds [%v, %v) do not contain it", x, idx, lower, upper)
+		}
+	}
+}
+
+func TestExpHistogramDownscaleKeepsBucketBoundsConsistent(t *testing.T) {
+	h := NewExpHistogram(4, 8)
+
+	values := []float64{1, 1.2, 1.5, 2, 3, 5, 8, 13, 21, 34, 55, 89, 144, 0.5, 0.2, 0.1}
+	for _, v := range values {
+		h.Observe(v)
+	}
+
+	if h.schema >= 4 {
+		t.Fatalf("expected downscale to have triggered, schema is still %d", h.schema)
+	}
+
+	for idx := range h.positive {
+		lower, upper := h.bucketBounds(idx)
+		if lower <= 0 || upper <= lower {
+			t.Errorf("bucket %d has invalid bounds [%v, %v)", idx, lower, upper)
+		}
+	}
+}
+
+func TestExpHistogramQuantileAndCDFAgreeAfterDownscale(t *testing.T) {
+	h := NewExpHistogram(6, 16)
+
+	for i := 1; i <= 500; i++ {
+		h.Observe(float64(i))
+	}
+
+	median := h.Quantile(0.5)
+	if median < 200 || median > 300 {
+		t.Errorf("Quantile(0.5) over 1..500 = %v, want within [200, 300]", median)
+	}
+
+	cdf := h.CDF(median)
+	if cdf < 0.4 || cdf > 0.6 {
+		t.Errorf("CDF(median) = %v, want within [0.4, 0.6]", cdf)
+	}
+}
+
+func TestFloorDiv(t *testing.T) {
+	cases := []struct{ a, b, want int }{
+		{4, 2, 2},
+		{5, 2, 2},
+		{-5, 2, -3},
+		{-4, 2, -2},
+		{0, 2, 0},
+	}
+	for _, c := range cases {
+		if got := floorDiv(c.a, c.b); got != c.want {
+			t.Errorf("floorDiv(%d, %d) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestExpHistogramMergeAlignsDifferentSchemas(t *testing.T) {
+	a := NewExpHistogram(6, 0)
+	b := NewExpHistogram(3, 0)
+
+	for i := 1; i <= 100; i++ {
+		a.Observe(float64(i))
+	}
+	for i := 101; i <= 200; i++ {
+		b.Observe(float64(i))
+	}
+
+	a.Merge(b)
+
+	if a.count != 200 {
+		t.Errorf("merged count = %d, want 200", a.count)
+	}
+	if math.Abs(a.sum-20100) > 1e-6 {
+		t.Errorf("merged sum = %v, want 20100", a.sum)
+	}
+}
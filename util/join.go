@@ -0,0 +1,192 @@
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// JoinOptions configures JoinInfo and JoinInfoLatest's conflict handling.
+type JoinOptions struct {
+	// DropOnConflict drops a label instead of returning an error when two
+	// matching info rows disagree on its value.
+	DropOnConflict bool
+}
+
+// JoinInfo merges, for each row in data, the union of the selected label
+// columns from every row in info whose "on" columns match -- mirroring the
+// semantics of Prometheus's info() function. An index built over info keyed
+// by the "on" tuple keeps the join O(n+m) rather than O(n*m). On a
+// multi-match with conflicting values for a label it returns an error,
+// unless opts requests the label be dropped instead.
+//
+// selectors takes the slice form rather than the ...string a join-only
+// signature would use, because opts ...JoinOptions already claims the
+// trailing variadic position -- Go allows only one per signature.
+func JoinInfo(data, info []map[string]interface{}, on, selectors []string, opts ...JoinOptions) ([]map[string]interface{}, error) {
+	var o JoinOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	index := indexInfo(info, on)
+
+	result := make([]map[string]interface{}, len(data))
+	for i, row := range data {
+		merged, err := joinRow(row, index, on, selectors, o)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		result[i] = merged
+	}
+
+	return result, nil
+}
+
+// JoinInfoLatest is like JoinInfo but, for info rows that carry a "time"
+// column, only considers the most recent match not after the data row's own
+// "time" value -- useful for corporate-action and reference-data tables that
+// change over time.
+//
+// selectors takes the slice form for the same reason as JoinInfo: opts
+// ...JoinOptions already occupies the trailing variadic position.
+func JoinInfoLatest(data, info []map[string]interface{}, on, selectors []string, opts ...JoinOptions) ([]map[string]interface{}, error) {
+	var o JoinOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	index := indexInfo(info, on)
+
+	result := make([]map[string]interface{}, len(data))
+	for i, row := range data {
+		key, ok := joinKey(row, on)
+		matches := index[key]
+		if ok {
+			if rowTime, hasTime := row["time"].(time.Time); hasTime {
+				matches = latestNotAfter(matches, rowTime)
+			}
+		}
+
+		merged := copyRow(row)
+		labels, err := mergeLabels(matches, selectors, o.DropOnConflict)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		for k, v := range labels {
+			merged[k] = v
+		}
+		result[i] = merged
+	}
+
+	return result, nil
+}
+
+func joinRow(row map[string]interface{}, index map[string][]map[string]interface{}, on, selectors []string, o JoinOptions) (map[string]interface{}, error) {
+	merged := copyRow(row)
+
+	key, ok := joinKey(row, on)
+	if !ok {
+		return merged, nil
+	}
+
+	labels, err := mergeLabels(index[key], selectors, o.DropOnConflict)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return merged, nil
+}
+
+func copyRow(row map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		merged[k] = v
+	}
+	return merged
+}
+
+func indexInfo(info []map[string]interface{}, on []string) map[string][]map[string]interface{} {
+	index := make(map[string][]map[string]interface{})
+	for _, row := range info {
+		key, ok := joinKey(row, on)
+		if !ok {
+			continue
+		}
+		index[key] = append(index[key], row)
+	}
+	return index
+}
+
+// joinKey builds a composite key from row's "on" columns. A row missing any
+// "on" column does not participate in the join.
+func joinKey(row map[string]interface{}, on []string) (string, bool) {
+	key := ""
+	for _, col := range on {
+		v, ok := row[col]
+		if !ok {
+			return "", false
+		}
+		key += fmt.Sprintf("\x1f%v", v)
+	}
+	return key, true
+}
+
+// mergeLabels unions the selected label columns across matches. A label
+// that disagrees across matches is either reported as an error or dropped,
+// depending on dropOnConflict. Values are compared with reflect.DeepEqual
+// rather than != since a selected column's value is not guaranteed to be a
+// comparable type.
+func mergeLabels(matches []map[string]interface{}, selectors []string, dropOnConflict bool) (map[string]interface{}, error) {
+	labels := make(map[string]interface{}, len(selectors))
+	dropped := make(map[string]bool, len(selectors))
+
+	for _, match := range matches {
+		for _, sel := range selectors {
+			v, ok := match[sel]
+			if !ok || dropped[sel] {
+				continue
+			}
+			if existing, ok := labels[sel]; ok && !reflect.DeepEqual(existing, v) {
+				if dropOnConflict {
+					delete(labels, sel)
+					dropped[sel] = true
+					continue
+				}
+				return nil, fmt.Errorf("conflicting values for label %q: %v vs %v", sel, existing, v)
+			}
+			labels[sel] = v
+		}
+	}
+
+	return labels, nil
+}
+
+// latestNotAfter keeps only the match with the most recent "time" not after
+// asOf, plus any matches that carry no "time" field at all.
+func latestNotAfter(matches []map[string]interface{}, asOf time.Time) []map[string]interface{} {
+	var best map[string]interface{}
+	var bestTime time.Time
+	var untimed []map[string]interface{}
+
+	for _, match := range matches {
+		t, ok := match["time"].(time.Time)
+		if !ok {
+			untimed = append(untimed, match)
+			continue
+		}
+		if t.After(asOf) {
+			continue
+		}
+		if best == nil || t.After(bestTime) {
+			best, bestTime = match, t
+		}
+	}
+
+	if best == nil {
+		return untimed
+	}
+	return append(untimed, best)
+}
@@ -0,0 +1,341 @@
+package util
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/siqueiraa/util/util/metrics"
+)
+
+// defaultMaxSizeInBytes is the active segment size CreateLogger rotates at
+// when LoggerOptions.MaxSize is left at zero.
+const defaultMaxSizeInBytes = int64(1024 * 1024) // 1 MB
+
+// LoggerOptions configures CreateLogger's segment rotation. The zero value
+// rotates at defaultMaxSizeInBytes, never ages a segment out, keeps every
+// rotated segment, and does not compress them.
+type LoggerOptions struct {
+	// Dir is the directory the active segment and its rotated siblings live
+	// in. Defaults to the current working directory.
+	Dir string
+	// MaxSize rotates the active segment once it would cross this size in
+	// bytes.
+	MaxSize int64
+	// MaxAge rotates the active segment once it has been open this long,
+	// regardless of size. Zero disables age-based rotation.
+	MaxAge time.Duration
+	// MaxSegments caps how many rotated segments are kept on disk; the
+	// oldest are removed first once a new one lands. Zero keeps all of them.
+	MaxSegments int
+	// Compress gzips a segment in the background right after it is rotated
+	// out.
+	Compress bool
+	// Mode is the file mode used when creating a segment. Defaults to 0644.
+	Mode os.FileMode
+	// SegmentHook, if set, is called with the final path of every segment
+	// once it has been rotated out (and compressed, if Compress is set), so
+	// callers can ship it to object storage.
+	SegmentHook func(path string)
+}
+
+// LogSegment describes one rotated-out segment file on disk.
+type LogSegment struct {
+	Path       string
+	Seq        int
+	Timestamp  time.Time
+	Compressed bool
+}
+
+// Open returns the segment's (decompressed, if needed) contents.
+func (s LogSegment) Open() (io.ReadCloser, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !s.Compressed {
+		return f, nil
+	}
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &gzipReadCloser{gr: gr, f: f}, nil
+}
+
+type gzipReadCloser struct {
+	gr *gzip.Reader
+	f  *os.File
+}
+
+func (g *gzipReadCloser) Read(p []byte) (int, error) { return g.gr.Read(p) }
+
+func (g *gzipReadCloser) Close() error {
+	if err := g.gr.Close(); err != nil {
+		g.f.Close()
+		return err
+	}
+	return g.f.Close()
+}
+
+// CreateLogger opens (or creates) fileName's active log segment under
+// opts.Dir and wraps it in a *log.Logger. Once the active segment would
+// cross opts.MaxSize or opts.MaxAge, it is atomically renamed to
+// "<fileName>-<unix-nanos>-<seq>.log", optionally gzipped in the
+// background, and a fresh active segment is opened in its place -- unlike
+// the previous truncate-to-zero behaviour, no data is discarded on rotation.
+// Rotated segments are only removed when opts.MaxSegments is set, oldest
+// first.
+// Writes are serialised through an internal mutex and the outgoing segment
+// is fsynced before rename, so rotation is safe under concurrent Print
+// calls. The returned io.Closer must be closed by the caller when the
+// logger is no longer needed.
+func CreateLogger(fileName string, opts LoggerOptions) (*log.Logger, io.Closer, error) {
+	dir := opts.Dir
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, nil, err
+		}
+		dir = wd
+	}
+
+	w, err := newRotatingWriter(dir, fileName, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger := log.New(w, "", log.LstdFlags|log.Lmicroseconds)
+	return logger, w, nil
+}
+
+// ReplayLogger lists prefix's segments under dir in chronological order, so
+// operators can reconstruct a service's history across restarts.
+func ReplayLogger(dir, prefix string) ([]LogSegment, error) {
+	return listSegments(dir, prefix)
+}
+
+// rotatingWriter is an io.WriteCloser that transparently rotates the active
+// segment of a segmented, WAL-style log.
+type rotatingWriter struct {
+	mu       sync.Mutex
+	dir      string
+	prefix   string
+	opts     LoggerOptions
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	seq      int
+}
+
+func activeLogPath(dir, prefix string) string {
+	return filepath.Join(dir, prefix+".log")
+}
+
+func newRotatingWriter(dir, prefix string, opts LoggerOptions) (*rotatingWriter, error) {
+	mode := opts.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+
+	f, err := os.OpenFile(activeLogPath(dir, prefix), os.O_APPEND|os.O_CREATE|os.O_WRONLY, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &rotatingWriter{
+		dir:      dir,
+		prefix:   prefix,
+		opts:     opts,
+		file:     f,
+		size:     info.Size(),
+		openedAt: time.Now(),
+	}, nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.shouldRotate(len(p)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	metrics.Default.Gauge("log_file_size_bytes").Set(fmt.Sprintf("file=%q", w.file.Name()), float64(w.size))
+	return n, err
+}
+
+func (w *rotatingWriter) shouldRotate(next int) bool {
+	maxSize := w.opts.MaxSize
+	if maxSize == 0 {
+		maxSize = defaultMaxSizeInBytes
+	}
+	if w.size+int64(next) > maxSize {
+		return true
+	}
+	return w.opts.MaxAge > 0 && time.Since(w.openedAt) >= w.opts.MaxAge
+}
+
+// rotate fsyncs and renames the active segment out, opens a fresh one in its
+// place, and hands the outgoing segment off to be compressed, pruned, and
+// hooked in the background. Callers must hold w.mu.
+func (w *rotatingWriter) rotate() error {
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+
+	w.seq++
+	outPath := filepath.Join(w.dir, fmt.Sprintf("%s-%d-%d.log", w.prefix, time.Now().UnixNano(), w.seq))
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(activeLogPath(w.dir, w.prefix), outPath); err != nil {
+		return err
+	}
+	metrics.Default.Counter("log_rotations_total").Inc(fmt.Sprintf("file=%q", activeLogPath(w.dir, w.prefix)))
+
+	mode := w.opts.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	f, err := os.OpenFile(activeLogPath(w.dir, w.prefix), os.O_APPEND|os.O_CREATE|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.size = 0
+	w.openedAt = time.Now()
+
+	go w.finishSegment(outPath)
+	return nil
+}
+
+func (w *rotatingWriter) finishSegment(path string) {
+	if w.opts.Compress {
+		if compressed, err := compressSegment(path); err == nil {
+			path = compressed
+		}
+	}
+	w.pruneSegments()
+	if w.opts.SegmentHook != nil {
+		w.opts.SegmentHook(path)
+	}
+}
+
+func compressSegment(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return path, err
+	}
+	defer in.Close()
+
+	outPath := path + ".gz"
+	out, err := os.Create(outPath)
+	if err != nil {
+		return path, err
+	}
+
+	gw := gzip.NewWriter(out)
+	if _, err := io.Copy(gw, in); err != nil {
+		gw.Close()
+		out.Close()
+		return path, err
+	}
+	if err := gw.Close(); err != nil {
+		out.Close()
+		return path, err
+	}
+	if err := out.Close(); err != nil {
+		return path, err
+	}
+
+	_ = os.Remove(path)
+	return outPath, nil
+}
+
+func (w *rotatingWriter) pruneSegments() {
+	if w.opts.MaxSegments <= 0 {
+		return
+	}
+	segments, err := listSegments(w.dir, w.prefix)
+	if err != nil || len(segments) <= w.opts.MaxSegments {
+		return
+	}
+	for _, seg := range segments[:len(segments)-w.opts.MaxSegments] {
+		_ = os.Remove(seg.Path)
+	}
+}
+
+// Close fsyncs and closes the active segment.
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	return w.file.Close()
+}
+
+var segmentNameRE = regexp.MustCompile(`^(.+)-(\d+)-(\d+)\.log(\.gz)?$`)
+
+// listSegments returns prefix's rotated-out segments under dir in
+// chronological order.
+func listSegments(dir, prefix string) ([]LogSegment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []LogSegment
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m := segmentNameRE.FindStringSubmatch(entry.Name())
+		if m == nil || m[1] != prefix {
+			continue
+		}
+		nanos, err := strconv.ParseInt(m[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		seq, err := strconv.Atoi(m[3])
+		if err != nil {
+			continue
+		}
+		segments = append(segments, LogSegment{
+			Path:       filepath.Join(dir, entry.Name()),
+			Seq:        seq,
+			Timestamp:  time.Unix(0, nanos),
+			Compressed: m[4] == ".gz",
+		})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].Timestamp.Equal(segments[j].Timestamp) {
+			return segments[i].Seq < segments[j].Seq
+		}
+		return segments[i].Timestamp.Before(segments[j].Timestamp)
+	})
+	return segments, nil
+}
@@ -0,0 +1,165 @@
+package util
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCreateLoggerRotatesAndReplays(t *testing.T) {
+	dir := t.TempDir()
+
+	logger, closer, err := CreateLogger("trades", LoggerOptions{Dir: dir, MaxSize: 200})
+	if err != nil {
+		t.Fatalf("CreateLogger: %v", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		logger.Println("order filled at price 100.25 for symbol BTC/USD")
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	segments, err := ReplayLogger(dir, "trades")
+	if err != nil {
+		t.Fatalf("ReplayLogger: %v", err)
+	}
+	if len(segments) == 0 {
+		t.Fatal("expected at least one rotated segment, got none")
+	}
+
+	for _, seg := range segments {
+		r, err := seg.Open()
+		if err != nil {
+			t.Fatalf("Open %s: %v", seg.Path, err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("ReadAll %s: %v", seg.Path, err)
+		}
+		if len(content) == 0 {
+			t.Errorf("segment %s is empty", seg.Path)
+		}
+	}
+
+	if _, err := os.Stat(activeLogPath(dir, "trades")); err != nil {
+		t.Errorf("active segment missing after rotation: %v", err)
+	}
+}
+
+func TestCreateLoggerNoDataLossAcrossRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	logger, closer, err := CreateLogger("audit", LoggerOptions{Dir: dir, MaxSize: 32})
+	if err != nil {
+		t.Fatalf("CreateLogger: %v", err)
+	}
+
+	const lines = 50
+	for i := 0; i < lines; i++ {
+		logger.Println("audit entry")
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	segments, err := ReplayLogger(dir, "audit")
+	if err != nil {
+		t.Fatalf("ReplayLogger: %v", err)
+	}
+
+	total := 0
+	countLines := func(r io.Reader) int {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+		n := 0
+		for _, b := range content {
+			if b == '\n' {
+				n++
+			}
+		}
+		return n
+	}
+
+	for _, seg := range segments {
+		r, err := seg.Open()
+		if err != nil {
+			t.Fatalf("Open %s: %v", seg.Path, err)
+		}
+		total += countLines(r)
+		r.Close()
+	}
+	active, err := os.Open(activeLogPath(dir, "audit"))
+	if err != nil {
+		t.Fatalf("open active segment: %v", err)
+	}
+	total += countLines(active)
+	active.Close()
+
+	if total != lines {
+		t.Errorf("round-tripped %d log lines across rotation, want %d", total, lines)
+	}
+}
+
+func TestCreateLoggerCompressedSegmentRoundTrips(t *testing.T) {
+	dir := t.TempDir()
+
+	hooked := make(chan string, 32)
+	logger, closer, err := CreateLogger("compressed", LoggerOptions{
+		Dir:         dir,
+		MaxSize:     200,
+		Compress:    true,
+		SegmentHook: func(path string) { hooked <- path },
+	})
+	if err != nil {
+		t.Fatalf("CreateLogger: %v", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		logger.Println("compressed segment content")
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	select {
+	case <-hooked:
+	case <-time.After(2 * time.Second):
+		t.Fatal("SegmentHook was never called")
+	}
+
+	segments, err := ReplayLogger(dir, "compressed")
+	if err != nil {
+		t.Fatalf("ReplayLogger: %v", err)
+	}
+	if len(segments) == 0 {
+		t.Fatal("expected at least one rotated segment, got none")
+	}
+
+	for _, seg := range segments {
+		if !seg.Compressed {
+			continue
+		}
+		r, err := seg.Open()
+		if err != nil {
+			t.Fatalf("Open compressed segment %s: %v", seg.Path, err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("decompress %s: %v", seg.Path, err)
+		}
+		if len(content) == 0 {
+			t.Errorf("decompressed segment %s is empty", seg.Path)
+		}
+		if filepath.Ext(seg.Path) != ".gz" {
+			t.Errorf("Compressed segment path %s does not end in .gz", seg.Path)
+		}
+	}
+}
@@ -0,0 +1,459 @@
+// Package metrics instruments the util package's helpers (loggers, Parquet
+// I/O, resampling, panic recovery) with counters, gauges, and resetting
+// timers, and exposes them through pluggable Prometheus and InfluxDB
+// reporters.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Counter is a monotonically increasing value, optionally split by a raw
+// label string (e.g. `caller="util.go:60"`).
+type Counter struct {
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+func newCounter() *Counter { return &Counter{values: make(map[string]int64)} }
+
+// Inc increments the counter for labels by 1.
+func (c *Counter) Inc(labels string) { c.Add(labels, 1) }
+
+// Add increments the counter for labels by delta.
+func (c *Counter) Add(labels string, delta int64) {
+	c.mu.Lock()
+	c.values[labels] += delta
+	c.mu.Unlock()
+}
+
+func (c *Counter) snapshot() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]int64, len(c.values))
+	for k, v := range c.values {
+		out[k] = v
+	}
+	return out
+}
+
+// Gauge is a value that can go up or down, optionally split by a raw label
+// string.
+type Gauge struct {
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newGauge() *Gauge { return &Gauge{values: make(map[string]float64)} }
+
+// Set assigns v to the gauge for labels.
+func (g *Gauge) Set(labels string, v float64) {
+	g.mu.Lock()
+	g.values[labels] = v
+	g.mu.Unlock()
+}
+
+// Add adjusts the gauge for labels by delta.
+func (g *Gauge) Add(labels string, delta float64) {
+	g.mu.Lock()
+	g.values[labels] += delta
+	g.mu.Unlock()
+}
+
+func (g *Gauge) snapshot() map[string]float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := make(map[string]float64, len(g.values))
+	for k, v := range g.values {
+		out[k] = v
+	}
+	return out
+}
+
+// TimerSnapshot is the min/max/mean/percentiles observed over one interval.
+type TimerSnapshot struct {
+	Count int
+	Min   float64
+	Max   float64
+	Mean  float64
+	P50   float64
+	P99   float64
+}
+
+// ResettingTimer captures per-interval latency observations (in
+// milliseconds) and is atomically snapshotted and cleared on read, so a
+// short-lived spike doesn't get smoothed away by a lifetime-cumulative
+// average. Because Snapshot clears what it reads, a timer must have exactly
+// one reader: if two reporters (e.g. a PrometheusHandler and an
+// InfluxPushReporter) share a Registry, each Render call steals the
+// interval's samples out from under the other. Give each reporter its own
+// Registry (via NewRegistry) if more than one needs to observe timers.
+type ResettingTimer struct {
+	mu      sync.Mutex
+	samples []float64
+}
+
+func newResettingTimer() *ResettingTimer { return &ResettingTimer{} }
+
+// Observe records a latency sample.
+func (t *ResettingTimer) Observe(d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	t.mu.Lock()
+	t.samples = append(t.samples, ms)
+	t.mu.Unlock()
+}
+
+// Snapshot returns the stats for the samples observed since the last
+// Snapshot call and clears them.
+func (t *ResettingTimer) Snapshot() TimerSnapshot {
+	t.mu.Lock()
+	samples := t.samples
+	t.samples = nil
+	t.mu.Unlock()
+
+	if len(samples) == 0 {
+		return TimerSnapshot{}
+	}
+
+	sort.Float64s(samples)
+	sum := 0.0
+	for _, s := range samples {
+		sum += s
+	}
+
+	return TimerSnapshot{
+		Count: len(samples),
+		Min:   samples[0],
+		Max:   samples[len(samples)-1],
+		Mean:  sum / float64(len(samples)),
+		P50:   percentile(samples, 0.5),
+		P99:   percentile(samples, 0.99),
+	}
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	idx := int(p * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+// Registry holds a process's named counters, gauges, and timers. The zero
+// value is not usable; create one with NewRegistry.
+type Registry struct {
+	mu       sync.Mutex
+	counters map[string]*Counter
+	gauges   map[string]*Gauge
+	timers   map[string]*ResettingTimer
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		counters: make(map[string]*Counter),
+		gauges:   make(map[string]*Gauge),
+		timers:   make(map[string]*ResettingTimer),
+	}
+}
+
+// Counter returns the named counter, creating it on first use.
+func (r *Registry) Counter(name string) *Counter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.counters[name]
+	if !ok {
+		c = newCounter()
+		r.counters[name] = c
+	}
+	return c
+}
+
+// Gauge returns the named gauge, creating it on first use.
+func (r *Registry) Gauge(name string) *Gauge {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.gauges[name]
+	if !ok {
+		g = newGauge()
+		r.gauges[name] = g
+	}
+	return g
+}
+
+// Timer returns the named resetting timer, creating it on first use.
+func (r *Registry) Timer(name string) *ResettingTimer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.timers[name]
+	if !ok {
+		t = newResettingTimer()
+		r.timers[name] = t
+	}
+	return t
+}
+
+func (r *Registry) snapshot() (map[string]*Counter, map[string]*Gauge, map[string]*ResettingTimer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counters := make(map[string]*Counter, len(r.counters))
+	for k, v := range r.counters {
+		counters[k] = v
+	}
+	gauges := make(map[string]*Gauge, len(r.gauges))
+	for k, v := range r.gauges {
+		gauges[k] = v
+	}
+	timers := make(map[string]*ResettingTimer, len(r.timers))
+	for k, v := range r.timers {
+		timers[k] = v
+	}
+	return counters, gauges, timers
+}
+
+// Default is the registry used by util's own instrumentation
+// (CreateLogger, ReadParquet, GenerateParquet, ResampleOHLCV, RecoverPanic).
+// Callers that want an isolated registry can create one with NewRegistry.
+var Default = NewRegistry()
+
+// Reporter renders a Registry's current state into a backend's wire format.
+type Reporter interface {
+	Render(r *Registry) []byte
+}
+
+// PrometheusReporter renders a Registry in Prometheus text exposition
+// format.
+type PrometheusReporter struct{}
+
+// Render implements Reporter. Render clears every timer it reads (see
+// ResettingTimer), so a Registry must not be rendered by more than one
+// reporter.
+func (PrometheusReporter) Render(r *Registry) []byte {
+	counters, gauges, timers := r.snapshot()
+
+	var sb strings.Builder
+	for name, c := range counters {
+		for labels, v := range c.snapshot() {
+			sb.WriteString(name)
+			writeLabels(&sb, labels)
+			fmt.Fprintf(&sb, " %d\n", v)
+		}
+	}
+	for name, g := range gauges {
+		for labels, v := range g.snapshot() {
+			sb.WriteString(name)
+			writeLabels(&sb, labels)
+			fmt.Fprintf(&sb, " %s\n", strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	}
+	for name, t := range timers {
+		snap := t.Snapshot()
+		for _, suffix := range []string{"count", "min", "max", "mean", "p50", "p99"} {
+			var v float64
+			switch suffix {
+			case "count":
+				v = float64(snap.Count)
+			case "min":
+				v = snap.Min
+			case "max":
+				v = snap.Max
+			case "mean":
+				v = snap.Mean
+			case "p50":
+				v = snap.P50
+			case "p99":
+				v = snap.P99
+			}
+			fmt.Fprintf(&sb, "%s_%s %s\n", name, suffix, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	}
+	return []byte(sb.String())
+}
+
+func writeLabels(sb *strings.Builder, labels string) {
+	if labels == "" {
+		return
+	}
+	sb.WriteString("{")
+	sb.WriteString(labels)
+	sb.WriteString("}")
+}
+
+// PrometheusHandler serves a Registry's current state in Prometheus text
+// exposition format on every request.
+type PrometheusHandler struct {
+	Registry *Registry
+	Reporter Reporter
+}
+
+// NewPrometheusHandler creates a handler for r using the default
+// PrometheusReporter.
+func NewPrometheusHandler(r *Registry) *PrometheusHandler {
+	return &PrometheusHandler{Registry: r, Reporter: PrometheusReporter{}}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *PrometheusHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write(h.Reporter.Render(h.Registry))
+}
+
+// InfluxLineReporter renders a Registry as InfluxDB line protocol.
+type InfluxLineReporter struct {
+	Measurement string
+}
+
+// Render implements Reporter. Render clears every timer it reads (see
+// ResettingTimer), so a Registry must not be rendered by more than one
+// reporter.
+func (p InfluxLineReporter) Render(r *Registry) []byte {
+	measurement := p.Measurement
+	if measurement == "" {
+		measurement = "util"
+	}
+
+	counters, gauges, timers := r.snapshot()
+	now := time.Now().UnixNano()
+
+	var sb strings.Builder
+	for name, c := range counters {
+		for labels, v := range c.snapshot() {
+			writeLine(&sb, measurement, name, labels, fmt.Sprintf("value=%di", v), now)
+		}
+	}
+	for name, g := range gauges {
+		for labels, v := range g.snapshot() {
+			writeLine(&sb, measurement, name, labels, fmt.Sprintf("value=%s", strconv.FormatFloat(v, 'f', -1, 64)), now)
+		}
+	}
+	for name, t := range timers {
+		snap := t.Snapshot()
+		fields := fmt.Sprintf(
+			"count=%di,min=%s,max=%s,mean=%s,p50=%s,p99=%s",
+			snap.Count,
+			strconv.FormatFloat(snap.Min, 'f', -1, 64),
+			strconv.FormatFloat(snap.Max, 'f', -1, 64),
+			strconv.FormatFloat(snap.Mean, 'f', -1, 64),
+			strconv.FormatFloat(snap.P50, 'f', -1, 64),
+			strconv.FormatFloat(snap.P99, 'f', -1, 64),
+		)
+		writeLine(&sb, measurement, name, "", fields, now)
+	}
+	return []byte(sb.String())
+}
+
+// writeLine appends one InfluxDB line protocol row. labels is the raw
+// Prometheus-style blob counters/gauges are keyed by (e.g.
+// `caller="util.go:60"`); it is parsed into key/value pairs and each part is
+// escaped per line protocol rules rather than spliced in verbatim, since a
+// Prometheus-quoted tag value is not valid line protocol.
+func writeLine(sb *strings.Builder, measurement, name, labels, fields string, ts int64) {
+	sb.WriteString(measurement)
+	sb.WriteString(",metric=")
+	sb.WriteString(escapeLineProtocolTag(name))
+	for _, kv := range parsePromLabels(labels) {
+		sb.WriteString(",")
+		sb.WriteString(escapeLineProtocolTag(kv[0]))
+		sb.WriteString("=")
+		sb.WriteString(escapeLineProtocolTag(kv[1]))
+	}
+	sb.WriteString(" ")
+	sb.WriteString(fields)
+	fmt.Fprintf(sb, " %d\n", ts)
+}
+
+// parsePromLabels splits a Prometheus-style label blob
+// (`key="value",key2="value2"`) into key/value pairs, stripping the double
+// quotes callers wrap string values in.
+func parsePromLabels(labels string) [][2]string {
+	if labels == "" {
+		return nil
+	}
+
+	var pairs [][2]string
+	for _, part := range strings.Split(labels, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(kv[0])
+		val := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		pairs = append(pairs, [2]string{key, val})
+	}
+	return pairs
+}
+
+// escapeLineProtocolTag escapes backslashes, commas, equals signs, and
+// spaces the way InfluxDB line protocol requires for unquoted tag keys and
+// values.
+func escapeLineProtocolTag(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `,`, `\,`, `=`, `\=`, ` `, `\ `)
+	return r.Replace(s)
+}
+
+// InfluxPushReporter batches a Registry's snapshots into InfluxDB line
+// protocol and POSTs them to URL on a fixed flush interval.
+type InfluxPushReporter struct {
+	Registry *Registry
+	Reporter Reporter
+	URL      string
+	Interval time.Duration
+	Client   *http.Client
+
+	stop chan struct{}
+}
+
+// NewInfluxPushReporter creates a push reporter for r using the default
+// InfluxLineReporter.
+func NewInfluxPushReporter(r *Registry, url string, interval time.Duration) *InfluxPushReporter {
+	return &InfluxPushReporter{
+		Registry: r,
+		Reporter: InfluxLineReporter{},
+		URL:      url,
+		Interval: interval,
+		Client:   http.DefaultClient,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start launches the background flush loop. Call Stop to end it.
+func (p *InfluxPushReporter) Start() {
+	go func() {
+		ticker := time.NewTicker(p.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = p.Flush()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background flush loop started by Start.
+func (p *InfluxPushReporter) Stop() { close(p.stop) }
+
+// Flush renders the registry and pushes it to URL once, outside the regular
+// flush interval.
+func (p *InfluxPushReporter) Flush() error {
+	body := p.Reporter.Render(p.Registry)
+	if len(body) == 0 {
+		return nil
+	}
+
+	resp, err := p.Client.Post(p.URL, "text/plain; charset=utf-8", strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("influx push to %s failed: %s", p.URL, resp.Status)
+	}
+	return nil
+}
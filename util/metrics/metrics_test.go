@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEscapeLineProtocolTag(t *testing.T) {
+	cases := map[string]string{
+		"util.go:60": `util.go:60`,
+		"a,b":        `a\,b`,
+		"a=b":        `a\=b`,
+		"a b":        `a\ b`,
+		`a\b`:        `a\\b`,
+	}
+	for in, want := range cases {
+		if got := escapeLineProtocolTag(in); got != want {
+			t.Errorf("escapeLineProtocolTag(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParsePromLabels(t *testing.T) {
+	got := parsePromLabels(`caller="util.go:60",symbol="BTC/USD"`)
+	want := [][2]string{{"caller", "util.go:60"}, {"symbol", "BTC/USD"}}
+
+	if len(got) != len(want) {
+		t.Fatalf("parsePromLabels returned %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("pair %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestInfluxLineReporterEscapesLabels(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("panics_total").Inc(`caller="util.go:60"`)
+
+	line := string(InfluxLineReporter{}.Render(r))
+
+	if !strings.Contains(line, `caller=util.go:60`) {
+		t.Fatalf("Render did not emit the unquoted tag value: %s", line)
+	}
+	if strings.Contains(line, `"`) {
+		t.Fatalf("Render left a Prometheus-style quote in line protocol output: %s", line)
+	}
+}
+
+func TestInfluxLineReporterEscapesMetricName(t *testing.T) {
+	r := NewRegistry()
+	r.Gauge("weird metric,name").Set("", 1)
+
+	line := string(InfluxLineReporter{}.Render(r))
+
+	if !strings.Contains(line, `metric=weird\ metric\,name`) {
+		t.Fatalf("Render did not escape the metric name: %s", line)
+	}
+}
+
+func TestInfluxLineReporterUntaggedMetric(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("events_total").Inc("")
+
+	line := string(InfluxLineReporter{}.Render(r))
+
+	if !strings.HasPrefix(line, "util,metric=events_total value=1i ") {
+		t.Fatalf("unexpected line for untagged metric: %s", line)
+	}
+}
@@ -0,0 +1,258 @@
+package util
+
+import (
+	"math"
+	"sort"
+)
+
+// Target is a quantile the summary should keep tight, together with the
+// relative-rank error that is acceptable around it. Lower epsilon means a
+// larger (and costlier) summary, so trading-relevant quantiles (tails,
+// median) should usually get a smaller epsilon than the rest of the
+// distribution.
+type Target struct {
+	Quantile float64
+	Epsilon  float64
+}
+
+// qSample is one (v, g, delta) tuple of the biased quantile summary: v is the
+// observed value, g is the minimum rank gap since the previous tuple, and
+// delta is the maximum rank error for v.
+type qSample struct {
+	value float64
+	g     int64
+	delta int64
+}
+
+// compressEvery controls how many inserts happen between COMPRESS passes.
+// Compressing after every insert would keep the summary minimal but costs an
+// O(n) scan each time; batching amortizes that cost the way the CKM paper
+// describes.
+const compressEvery = 128
+
+// QuantileStream is a Cormode-Korn-Muthukrishnan biased quantile summary: a
+// single-pass, bounded-memory estimator for a configurable set of target
+// quantiles. Unlike Quantile, which sorts a copy of its whole input on every
+// call, Insert never sorts or copies caller data, so it is safe to feed from a
+// long-running OHLCV or z-score pipeline.
+type QuantileStream struct {
+	targets []Target
+	samples []qSample
+	n       int64
+	inserts int64
+}
+
+// NewQuantileStream creates a stream bounding error around the given
+// targets. With no targets it defaults to the tails and median most trading
+// pipelines care about (p1, p50, p99 at 0.5% error).
+func NewQuantileStream(targets ...Target) *QuantileStream {
+	if len(targets) == 0 {
+		targets = []Target{
+			{Quantile: 0.01, Epsilon: 0.005},
+			{Quantile: 0.5, Epsilon: 0.005},
+			{Quantile: 0.99, Epsilon: 0.005},
+		}
+	}
+	return &QuantileStream{targets: targets}
+}
+
+// Insert adds x to the summary.
+func (s *QuantileStream) Insert(x float64) {
+	s.n++
+
+	i := sort.Search(len(s.samples), func(i int) bool { return s.samples[i].value >= x })
+
+	var delta int64
+	if i > 0 && i < len(s.samples) {
+		var rank int64
+		for _, sample := range s.samples[:i] {
+			rank += sample.g
+		}
+		delta = s.invariant(rank)
+	}
+
+	s.samples = append(s.samples, qSample{})
+	copy(s.samples[i+1:], s.samples[i:])
+	s.samples[i] = qSample{value: x, g: 1, delta: delta}
+
+	s.inserts++
+	if s.inserts%compressEvery == 0 {
+		s.compress()
+	}
+}
+
+// Query returns an estimate of the phi-quantile (0<=phi<=1). It walks the
+// summary accumulating g until the cumulative rank passes the target rank
+// adjusted by the epsilon of the closest target.
+func (s *QuantileStream) Query(phi float64) float64 {
+	if len(s.samples) == 0 {
+		return 0
+	}
+	if phi <= 0 {
+		return s.samples[0].value
+	}
+	if phi >= 1 {
+		return s.samples[len(s.samples)-1].value
+	}
+
+	eps := s.epsilonNear(phi)
+	desired := int64(math.Ceil(phi*float64(s.n))) - int64(math.Floor(eps*float64(s.n)))
+	if desired < 0 {
+		desired = 0
+	}
+
+	var rank int64
+	for _, sample := range s.samples {
+		rank += sample.g
+		if rank > desired {
+			return sample.value
+		}
+	}
+	return s.samples[len(s.samples)-1].value
+}
+
+// Merge folds other's summary into s, so per-shard streams (e.g. one per
+// symbol or one per worker) can be combined into a global view.
+func (s *QuantileStream) Merge(other *QuantileStream) {
+	if other == nil || len(other.samples) == 0 {
+		return
+	}
+
+	merged := make([]qSample, 0, len(s.samples)+len(other.samples))
+	i, j := 0, 0
+	for i < len(s.samples) && j < len(other.samples) {
+		if s.samples[i].value <= other.samples[j].value {
+			merged = append(merged, s.samples[i])
+			i++
+		} else {
+			merged = append(merged, other.samples[j])
+			j++
+		}
+	}
+	merged = append(merged, s.samples[i:]...)
+	merged = append(merged, other.samples[j:]...)
+
+	s.samples = merged
+	s.n += other.n
+	s.compress()
+}
+
+// Reset clears the summary so the stream can be reused for the next window.
+func (s *QuantileStream) Reset() {
+	s.samples = nil
+	s.n = 0
+	s.inserts = 0
+}
+
+// invariant returns the maximum rank error allowed at rank, 0 at the ends.
+// Each target (phi, epsilon) allows 2*epsilon*rank/phi when rank <= phi*n and
+// 2*epsilon*(n-rank)/(1-phi) otherwise, truncated to an integer; the smallest
+// of these across all targets is returned.
+func (s *QuantileStream) invariant(rank int64) int64 {
+	if rank <= 0 || rank >= s.n {
+		return 0
+	}
+
+	best := int64(-1)
+	for _, t := range s.targets {
+		var v float64
+		if float64(rank) <= t.Quantile*float64(s.n) {
+			v = 2 * t.Epsilon * float64(rank) / t.Quantile
+		} else {
+			v = 2 * t.Epsilon * float64(s.n-rank) / (1 - t.Quantile)
+		}
+		iv := int64(v)
+		if best < 0 || iv < best {
+			best = iv
+		}
+	}
+	if best < 0 {
+		return 0
+	}
+	return best
+}
+
+// epsilonNear returns the epsilon of the target closest to phi.
+func (s *QuantileStream) epsilonNear(phi float64) float64 {
+	best := s.targets[0]
+	bestDist := math.Abs(phi - best.Quantile)
+	for _, t := range s.targets[1:] {
+		if d := math.Abs(phi - t.Quantile); d < bestDist {
+			best, bestDist = t, d
+		}
+	}
+	return best.Epsilon
+}
+
+// compress merges neighbouring tuples whose combined g, delta and rank gap
+// still satisfy the summary's error bound, keeping its size close to
+// O((1/epsilon)*log(epsilon*n)) instead of growing unbounded with n.
+func (s *QuantileStream) compress() {
+	if len(s.samples) < 3 {
+		return
+	}
+
+	merged := make([]qSample, 0, len(s.samples))
+	merged = append(merged, s.samples[len(s.samples)-1])
+
+	rank := s.n - s.samples[len(s.samples)-1].g
+	for i := len(s.samples) - 2; i >= 1; i-- {
+		cur := s.samples[i]
+		top := &merged[len(merged)-1]
+		if cur.g+top.g+top.delta <= s.invariant(rank) {
+			top.g += cur.g
+		} else {
+			merged = append(merged, cur)
+		}
+		rank -= cur.g
+	}
+	merged = append(merged, s.samples[0])
+
+	for i, j := 0, len(merged)-1; i < j; i, j = i+1, j-1 {
+		merged[i], merged[j] = merged[j], merged[i]
+	}
+	s.samples = merged
+}
+
+// QuantileOptions selects an alternative backend for Quantile.
+type QuantileOptions struct {
+	// Histogram, if set, estimates through an ExpHistogram instead of the
+	// default sort-and-interpolate path. Quantile observes data into it and
+	// then queries it, so Histogram accumulates across calls like any other
+	// ExpHistogram -- do not pass one that has already seen data unless you
+	// want it double-counted.
+	Histogram *ExpHistogram
+}
+
+// Quantile returns the p-quantile (0<=p<=1) of data using linear
+// interpolation between the two nearest sorted values. It sorts a local
+// copy rather than mutating the caller's slice. Long-running pipelines that
+// don't need exact results should prefer QuantileStream, which runs in
+// bounded memory without ever sorting.
+func Quantile(data []float64, p float64, opts ...QuantileOptions) float64 {
+	if len(opts) > 0 && opts[0].Histogram != nil {
+		h := opts[0].Histogram
+		for _, v := range data {
+			h.Observe(v)
+		}
+		return h.Quantile(p)
+	}
+
+	if len(data) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(data))
+	copy(sorted, data)
+	sort.Float64s(sorted)
+
+	index := p * (float64(len(sorted)) - 1)
+	lower := int(index)
+	upper := lower + 1
+	if upper >= len(sorted) {
+		upper = len(sorted) - 1
+	}
+
+	frac := index - float64(lower)
+	return sorted[lower] + frac*(sorted[upper]-sorted[lower])
+}
@@ -0,0 +1,77 @@
+package util
+
+import "testing"
+
+func TestQuantileLinearInterpolation(t *testing.T) {
+	data := []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	if got := Quantile(data, 0.5); got != 5.0 {
+		t.Errorf("Quantile(0..10, 0.5) = %v, want 5.0", got)
+	}
+	if got := Quantile(data, 0.25); got != 2.5 {
+		t.Errorf("Quantile(0..10, 0.25) = %v, want 2.5", got)
+	}
+}
+
+func TestQuantileDoesNotMutateInput(t *testing.T) {
+	data := []float64{-5, -1, -3, 10, 2}
+	want := []float64{-5, -1, -3, 10, 2}
+
+	if got := Quantile(data, 0.5); got != -1.0 {
+		t.Errorf("Quantile(%v, 0.5) = %v, want -1.0", want, got)
+	}
+	for i := range data {
+		if data[i] != want[i] {
+			t.Fatalf("Quantile mutated its input: got %v, want %v", data, want)
+		}
+	}
+}
+
+func TestQuantileEmpty(t *testing.T) {
+	if got := Quantile(nil, 0.5); got != 0 {
+		t.Errorf("Quantile(nil, 0.5) = %v, want 0", got)
+	}
+}
+
+func TestQuantileStreamApproximatesSortedQuantiles(t *testing.T) {
+	qs := NewQuantileStream(Target{Quantile: 0.5, Epsilon: 0.01})
+	for i := 0; i <= 1000; i++ {
+		qs.Insert(float64(i))
+	}
+
+	got := qs.Query(0.5)
+	if got < 480 || got > 520 {
+		t.Errorf("QuantileStream.Query(0.5) over 0..1000 = %v, want within [480, 520]", got)
+	}
+}
+
+func TestQuantileStreamReset(t *testing.T) {
+	qs := NewQuantileStream()
+	for i := 0; i < 100; i++ {
+		qs.Insert(float64(i))
+	}
+
+	qs.Reset()
+	if got := qs.Query(0.5); got != 0 {
+		t.Errorf("Query after Reset = %v, want 0", got)
+	}
+}
+
+func TestQuantileStreamMerge(t *testing.T) {
+	a := NewQuantileStream(Target{Quantile: 0.5, Epsilon: 0.01})
+	b := NewQuantileStream(Target{Quantile: 0.5, Epsilon: 0.01})
+
+	for i := 0; i <= 500; i++ {
+		a.Insert(float64(i))
+	}
+	for i := 501; i <= 1000; i++ {
+		b.Insert(float64(i))
+	}
+
+	a.Merge(b)
+
+	got := a.Query(0.5)
+	if got < 480 || got > 520 {
+		t.Errorf("merged QuantileStream.Query(0.5) over 0..1000 = %v, want within [480, 520]", got)
+	}
+}
@@ -12,12 +12,12 @@ import (
 	"regexp"
 	"runtime"
 	"runtime/debug"
-	"sort"
 	"strconv"
 	"time"
 	_ "time/tzdata"
 
 	"github.com/parquet-go/parquet-go"
+	"github.com/siqueiraa/util/util/metrics"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
@@ -58,8 +58,10 @@ func RecoverPanic(loggerErrorTrigger *log.Logger, restartFn func()) {
 		_, file, line, ok := runtime.Caller(2) // Note: Changed to 2 to capture the direct caller
 		if ok {
 			loggerErrorTrigger.Printf("Recovered from panic - %s:%d: %v\n", file, line, err)
+			metrics.Default.Counter("panics_total").Inc(fmt.Sprintf("caller=%q", file))
 		} else {
 			loggerErrorTrigger.Printf("Recovered from panic: %v\n", err)
+			metrics.Default.Counter("panics_total").Inc("caller=\"unknown\"")
 		}
 		loggerErrorTrigger.Printf("Stack trace:\n%s\n", string(debug.Stack()))
 
@@ -72,79 +74,6 @@ func RecoverPanic(loggerErrorTrigger *log.Logger, restartFn func()) {
 	}
 }
 
-const defaultMaxSizeInBytes = int64(1024 * 1024) // 1 MB
-
-func CreateLogger(fileName string, options ...interface{}) (*log.Logger, *os.File, error) {
-	var logFilePath string
-	var path string
-	var maxSizeInBytes int64
-
-	// Process options
-	for _, option := range options {
-		switch opt := option.(type) {
-		case string:
-			path = opt
-		case int64:
-			maxSizeInBytes = opt
-		}
-	}
-
-	// If path is not provided, use the project path
-	if path == "" {
-		projectPath, err := os.Getwd()
-		if err != nil {
-			return nil, nil, err
-		}
-		logFilePath = filepath.Join(projectPath, fileName)
-	} else {
-		logFilePath = filepath.Join(path, fileName)
-	}
-
-	// Check if maxSizeInBytes is provided by the user
-	if maxSizeInBytes == 0 {
-		// Use the default size if maxSizeInBytes is not provided
-		maxSizeInBytes = defaultMaxSizeInBytes
-	}
-
-	// Check if the log file exists
-	if _, err := os.Stat(logFilePath); os.IsNotExist(err) {
-		// Log file doesn't exist, create it
-		logFile, err := os.Create(logFilePath)
-		if err != nil {
-			return nil, nil, err
-		}
-		logger := log.New(logFile, "", log.LstdFlags|log.Lmicroseconds)
-		return logger, logFile, nil
-	}
-
-	// Log file already exists, open it for appending
-	logFile, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_WRONLY, os.ModeAppend)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	// Check if the log file exceeds the specified size limit
-	fileInfo, err := logFile.Stat()
-	if err != nil {
-		return nil, nil, err
-	}
-	if fileInfo.Size() > maxSizeInBytes {
-		// Truncate the log file
-		err = logFile.Truncate(0)
-		if err != nil {
-			return nil, nil, err
-		}
-		_, err = logFile.Seek(0, 0)
-		if err != nil {
-			return nil, nil, err
-		}
-	}
-
-	logger := log.New(logFile, "", log.LstdFlags|log.Lmicroseconds)
-
-	return logger, logFile, nil
-}
-
 func TimeUntilNext5Minutes() time.Duration {
 	now := time.Now()
 	roundedTime := now.Truncate(5 * time.Minute)
@@ -184,6 +113,9 @@ func GetParentDirectory() (string, error) {
 }
 
 func ReadParquet(fileName string, slicePtr interface{}) error {
+	start := time.Now()
+	defer func() { metrics.Default.Timer("parquet_read_duration_ms").Observe(time.Since(start)) }()
+
 	rf, err := os.Open(fileName)
 
 	if err != nil {
@@ -234,6 +166,9 @@ func ReadParquet(fileName string, slicePtr interface{}) error {
 	return nil
 }
 func GenerateParquet(data []map[string]interface{}, fileName string) error {
+	start := time.Now()
+	defer func() { metrics.Default.Timer("parquet_write_duration_ms").Observe(time.Since(start)) }()
+
 	// Get the sample map from the first element
 	if len(data) == 0 {
 		return errors.New("empty data slice")
@@ -382,6 +317,9 @@ func FormatCorrectTypes(data []map[string]interface{}) []map[string]interface{}
 
 // ResampleOHLCV takes a slice of OHLCV data (as maps) and resamples it to the specified time frame
 func ResampleOHLCV(data []map[string]interface{}, targetTimeFrame time.Duration) []map[string]interface{} {
+	start := time.Now()
+	defer func() { metrics.Default.Timer("resample_ohlcv_duration_ms").Observe(time.Since(start)) }()
+
 	resampledData := make(map[string]map[string]interface{})
 
 	for _, entry := range data {
@@ -564,7 +502,28 @@ func keepRecordsLastxMinutes(data []map[string]interface{}, minutes float64) []m
 
 	return results
 }
-func CalculateZScores(values []float64) []float64 {
+
+// ZScoreOptions selects an alternative backend for CalculateZScores.
+type ZScoreOptions struct {
+	// Histogram, if set, derives mean/stddev from an ExpHistogram's running
+	// sum/count and bucket spread instead of the exact two-pass calculation.
+	// CalculateZScores observes values into it before querying it, so
+	// Histogram accumulates across calls like any other ExpHistogram -- do
+	// not pass one that has already seen data unless you want it
+	// double-counted.
+	Histogram *ExpHistogram
+}
+
+func CalculateZScores(values []float64, opts ...ZScoreOptions) []float64 {
+	if len(opts) > 0 && opts[0].Histogram != nil {
+		h := opts[0].Histogram
+		for _, v := range values {
+			h.Observe(v)
+		}
+		mean, stdDev := h.meanAndStdDev()
+		return calculateZScores(values, mean, stdDev)
+	}
+
 	mean, stdDev := calculateMeanAndStdDev(values)
 	zScores := calculateZScores(values, mean, stdDev)
 	return zScores
@@ -597,29 +556,6 @@ func calculateZScores(values []float64, mean, stdDev float64) []float64 {
 	}
 	return zScores
 }
-func Quantile(data []float64, p float64) float64 {
-	if len(data) == 0 {
-		return 0
-	}
-
-	// Sort the data
-	sort.Float64s(data)
-
-	// Calculate the index for the specified quantile
-	index := p * (float64(len(data)) - 1)
-	lower := int(index)
-	upper := lower + 1
-
-	// Check if upper index is within bounds
-	if upper >= len(data) {
-		upper = len(data) - 1
-	}
-
-	// Interpolate between the two nearest data points
-	fracPart := index - float64(lower)
-	return data[lower] + fracPart*(data[upper]-data[lower])
-}
-
 func KeepHistoryMinute(data []map[string]interface{}, keepMinutes float64) []map[string]interface{} {
 	// Use a map to track the most recent record for each minute and symbol
 	latestRecords := make(map[string]map[string]interface{})